@@ -6,6 +6,7 @@ import (
 	"io"
 	"math"
 	"sync"
+	"time"
 
 	"github.com/ethereum-optimism/optimism/op-batcher/metrics"
 	"github.com/ethereum-optimism/optimism/op-node/rollup"
@@ -21,12 +22,30 @@ var ErrReorg = errors.New("block does not extend existing chain")
 
 type ChannelOutFactory func(cfg ChannelConfig, rollupCfg *rollup.Config) (derive.ChannelOut, error)
 
+// DAChoice is which DA type a channel's frames should be packed into for a
+// given TxData call: channels themselves are DA-agnostic, so this is decided
+// fresh per call rather than baked into the channel at creation time. See
+// TxData and nextTxData.
+//
+// ChannelConfigProvider.DAChoice() (defined alongside ChannelConfig in the
+// channel config source not included in this package) returns the current
+// choice, and channel.NextTxData(DAChoice) (defined alongside the channel
+// type) packs frames accordingly.
+type DAChoice int
+
+const (
+	DACalldata DAChoice = iota
+	DABlob
+)
+
 // channelManager stores a contiguous set of blocks & turns them into channels.
 // Upon receiving tx confirmation (or a tx failure), it does channel error handling.
 //
-// For simplicity, it only creates a single pending channel at a time & waits for
-// the channel to either successfully be submitted or timeout before creating a new
-// channel.
+// Up to cfg.MaxConcurrentChannels channels may be open and accepting block data
+// at once, e.g. a blobs-targeted channel and a calldata-targeted channel
+// compressing in parallel, rather than the single currentChannel this manager
+// used to maintain. A channel stops accepting new blocks once it is full, and
+// stays in channelQueue until it is either fully submitted or invalidated.
 // Public functions on channelManager are safe for concurrent access.
 type channelManager struct {
 	mu          sync.Mutex
@@ -37,37 +56,80 @@ type channelManager struct {
 
 	outFactory ChannelOutFactory
 
+	// store is the write-ahead log that channelManager appends to on every
+	// state-changing event, so Recover can reconstruct in-flight blocks and
+	// channels across a batcher restart instead of deriving everything from
+	// the sequencer via Clear. Defaults to a no-op store.
+	store ChannelStore
+
 	// All blocks since the last request for new tx data.
 	blocks queue.Queue[*types.Block]
 	// blockCursor is an index into blocks queue. It points at the next block
 	// to build a channel with. blockCursor = len(blocks) is reserved for when
 	// there are no blocks ready to build with.
 	blockCursor int
-	// The latest L1 block from all the L2 blocks in the most recently submitted channel.
-	// Used to track channel duration timeouts.
-	l1OriginLastSubmittedChannel eth.BlockID
+	// l1OriginLastSubmittedChannel tracks, per channel ID, the latest L1
+	// origin among that channel's blocks. Used as the duration-timeout
+	// baseline for channels created afterwards. Tracked per channel, rather
+	// than as a single rolling value, now that multiple channels may be
+	// open and submitting concurrently.
+	l1OriginLastSubmittedChannel map[string]eth.BlockID
+	// l1OriginBaseline is the L1 origin to fall back on when no channel has
+	// been submitted yet since the last Clear (e.g. right after startup or
+	// a reorg), so newly created channels still get a sane timeout baseline.
+	// Recover and pruneChannels also raise it to the origins of channels
+	// that are no longer tracked in l1OriginLastSubmittedChannel.
+	l1OriginBaseline eth.BlockID
 	// The default ChannelConfig to use for the next channel
 	defaultCfg ChannelConfig
 	// last block hash - for reorg detection
 	tip common.Hash
 
-	// channel to write new block data to
-	currentChannel *channel
+	// openChannels holds the channels that are still accepting new block
+	// data, i.e. for which !ch.IsFull(). At most cfg.MaxConcurrentChannels
+	// channels are open at once.
+	openChannels []*channel
+	// channelOpenedAt records when each open channel was created, keyed by
+	// channel ID, so closeOpenChannel can report how much wall-clock time
+	// (a proxy for compressor CPU time) it spent compressing.
+	channelOpenedAt map[string]time.Time
+	// nextChannelIdx is the index into openChannels that
+	// ensureChannelWithSpace tries first, so blocks stripe round-robin
+	// across open channels rather than always filling openChannels[0].
+	nextChannelIdx int
 	// channels to read frame data from, for writing batches onchain
 	channelQueue []*channel
 	// used to lookup channels by tx ID upon tx success / failure
 	txChannels map[string]*channel
+
+	// throttled tracks whether the pending-block queue has crossed the
+	// high watermark (ChannelConfig.MaxPendingBlocks) and is still above
+	// the low watermark (ChannelConfig.ThrottleThreshold). It provides
+	// hysteresis so IsFull doesn't flap while the queue hovers near the
+	// high watermark.
+	throttled bool
 }
 
-func NewChannelManager(log log.Logger, metr metrics.Metricer, cfgProvider ChannelConfigProvider, rollupCfg *rollup.Config) *channelManager {
+// NewChannelManager constructs a channelManager. store may be nil, in which
+// case no WAL is kept and state is always rebuilt from the sequencer on
+// restart, matching pre-persistence behavior; callers that want a batcher
+// restart to resume in-flight channels should pass a ChannelStore (e.g.
+// NewFileChannelStore) and call Recover once at startup.
+func NewChannelManager(log log.Logger, metr metrics.Metricer, cfgProvider ChannelConfigProvider, rollupCfg *rollup.Config, store ChannelStore) *channelManager {
+	if store == nil {
+		store = noopChannelStore{}
+	}
 	return &channelManager{
-		log:         log,
-		metr:        metr,
-		cfgProvider: cfgProvider,
-		defaultCfg:  cfgProvider.ChannelConfig(),
-		rollupCfg:   rollupCfg,
-		outFactory:  NewChannelOut,
-		txChannels:  make(map[string]*channel),
+		log:                          log,
+		metr:                         metr,
+		cfgProvider:                  cfgProvider,
+		defaultCfg:                   cfgProvider.ChannelConfig(),
+		rollupCfg:                    rollupCfg,
+		outFactory:                   NewChannelOut,
+		store:                        store,
+		txChannels:                   make(map[string]*channel),
+		l1OriginLastSubmittedChannel: make(map[string]eth.BlockID),
+		channelOpenedAt:              make(map[string]time.Time),
 	}
 }
 
@@ -83,17 +145,177 @@ func (s *channelManager) Clear(l1OriginLastSubmittedChannel eth.BlockID) {
 	s.log.Trace("clearing channel manager state")
 	s.blocks.Clear()
 	s.blockCursor = 0
-	s.l1OriginLastSubmittedChannel = l1OriginLastSubmittedChannel
+	s.l1OriginLastSubmittedChannel = make(map[string]eth.BlockID)
+	s.l1OriginBaseline = l1OriginLastSubmittedChannel
 	s.tip = common.Hash{}
-	s.currentChannel = nil
+	s.openChannels = nil
+	s.nextChannelIdx = 0
 	s.channelQueue = nil
 	s.txChannels = make(map[string]*channel)
+	s.channelOpenedAt = make(map[string]time.Time)
+}
+
+// Recover replays the WAL (if one was configured via NewChannelManager) to
+// reconstruct the pending-block queue, tip, and blockCursor left behind by a
+// previous batcher instance, instead of starting from a blank slate. It must
+// be called once, before the first AddL2Block/TxData call, typically right
+// after construction.
+//
+// blockCursor is only advanced across the contiguous prefix of consumed
+// blocks (WALBlockConsumed) whose channel has a WALTxConfirmed record with
+// FullySubmitted set, since
+// those blocks are already durably on L1 and rebuilding them into a new
+// channel would duplicate that submission, not just the local compression
+// work. channelQueue, openChannels and txChannels are NOT reconstructed: a
+// channel's compressor/frame state isn't persisted to the WAL, so there is
+// no way to resume one mid-compression or re-learn which in-flight tx
+// belongs to it. Blocks belonging to a channel that was opened, or even
+// closed, but never confirmed are therefore left at or after blockCursor
+// and get rebuilt into a fresh channel the next time TxData runs; this
+// trades a little duplicated compression (and, rarely, a duplicate L1
+// submission racing an unconfirmed-but-still-pending one) for never
+// silently dropping a block.
+//
+// l1OriginLastSubmittedChannel itself can't be restored either, since it's
+// keyed by the IDs of channels Recover can't reconstruct. Instead, the
+// highest inclusion block among all replayed WALTxConfirmed records is
+// folded into
+// l1OriginBaseline, the same fallback latestSubmittedL1Origin already uses
+// whenever no channel is currently tracked (e.g. right after Clear).
+func (s *channelManager) Recover() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.store.Replay()
+	if err != nil {
+		return fmt.Errorf("replaying channel store: %w", err)
+	}
+
+	openedChannels := make(map[string]bool)
+	confirmedChannels := make(map[string]bool)
+	var consumedChannelByIdx []string
+	var latestInclusion eth.BlockID
+
+	for _, ev := range events {
+		switch ev.Kind {
+		case WALBlockAdded:
+			block, err := ev.Block()
+			if err != nil {
+				s.log.Error("failed to decode WAL block-added event, skipping", "err", err)
+				continue
+			}
+			s.metr.RecordL2BlockInPendingQueue(block)
+			s.blocks.Enqueue(block)
+			s.tip = block.Hash()
+		case WALBlockConsumed:
+			consumedChannelByIdx = append(consumedChannelByIdx, ev.ChannelID)
+		case WALChannelOpened:
+			openedChannels[ev.ChannelID] = true
+		case WALTxConfirmed:
+			// A channel can need more than one tx; only count it as safely
+			// on L1 once FullySubmitted says there's no outstanding tx left,
+			// not on the first WALTxConfirmed seen for it. Otherwise a
+			// channel whose later tx never got submitted before a crash
+			// would have blockCursor advanced past all of its blocks below,
+			// silently dropping the blocks behind that never-sent tx.
+			if ev.FullySubmitted {
+				confirmedChannels[ev.ChannelID] = true
+			}
+			if ev.Inclusion.Number > latestInclusion.Number {
+				latestInclusion = ev.Inclusion
+			}
+		}
+	}
+
+	for _, chID := range consumedChannelByIdx {
+		if !confirmedChannels[chID] {
+			break
+		}
+		s.blockCursor++
+	}
+
+	if latestInclusion.Number > s.l1OriginBaseline.Number {
+		s.l1OriginBaseline = latestInclusion
+	}
+
+	var unconfirmed int
+	for id := range openedChannels {
+		if !confirmedChannels[id] {
+			unconfirmed++
+		}
+	}
+
+	s.log.Info("recovered channel manager state from WAL",
+		"blocks_recovered", s.blocks.Len(),
+		"block_cursor", s.blockCursor,
+		"channels_seen", len(openedChannels),
+		"unconfirmed_channels", unconfirmed,
+		"l1_origin_baseline", s.l1OriginBaseline)
+
+	return nil
 }
 
 func (s *channelManager) pendingBlocks() int {
 	return s.blocks.Len() - s.blockCursor
 }
 
+// PendingBlocks returns the number of blocks that have been queued via
+// AddL2Block but not yet consumed into a channel. The driver loop can
+// poll this (or IsFull) to decide whether to keep fetching unsafe L2
+// blocks from the sequencer.
+func (s *channelManager) PendingBlocks() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pendingBlocks()
+}
+
+// IsFull returns true if the channel manager is currently throttling
+// ingestion of new L2 blocks. Throttling activates once the pending-block
+// queue exceeds ChannelConfig.MaxPendingBlocks (the high watermark) and
+// stays active until the queue drains to ChannelConfig.ThrottleThreshold
+// (the low watermark), so callers should not try to derive the state from
+// PendingBlocks alone. AddL2Block does not itself enforce this; it is the
+// driver loop's responsibility to stop calling AddL2Block while IsFull
+// returns true, which bounds the batcher's memory use when the sequencer
+// outruns L1 submission.
+func (s *channelManager) IsFull() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isFull()
+}
+
+// isFull implements IsFull. Callers must hold s.mu.
+func (s *channelManager) isFull() bool {
+	cfg := s.defaultCfg
+	if cfg.MaxPendingBlocks == 0 {
+		// throttling disabled
+		return false
+	}
+
+	pending := s.pendingBlocks()
+	wasThrottled := s.throttled
+	switch {
+	case pending > cfg.MaxPendingBlocks:
+		s.throttled = true
+	case pending <= cfg.ThrottleThreshold:
+		s.throttled = false
+	}
+
+	if s.throttled != wasThrottled {
+		s.metr.RecordChannelManagerThrottled(s.throttled)
+		if s.throttled {
+			s.log.Warn("throttling L2 block ingestion: pending block queue above high watermark",
+				"pending_blocks", pending,
+				"max_pending_blocks", cfg.MaxPendingBlocks)
+		} else {
+			s.log.Info("no longer throttling L2 block ingestion: pending block queue below low watermark",
+				"pending_blocks", pending,
+				"throttle_threshold", cfg.ThrottleThreshold)
+		}
+	}
+	return s.throttled
+}
+
 // TxFailed records a transaction as failed. It will attempt to resubmit the data
 // in the failed transaction.
 func (s *channelManager) TxFailed(_id txID) {
@@ -103,6 +325,9 @@ func (s *channelManager) TxFailed(_id txID) {
 	if channel, ok := s.txChannels[id]; ok {
 		delete(s.txChannels, id)
 		channel.TxFailed(id)
+		if err := s.store.Append(WALEvent{Kind: WALTxFailed, ChannelID: channel.ID().String(), TxID: id}); err != nil {
+			s.log.Error("failed to append tx-failed WAL event", "id", id, "err", err)
+		}
 	} else {
 		s.log.Warn("transaction from unknown channel marked as failed", "id", id)
 	}
@@ -116,7 +341,21 @@ func (s *channelManager) TxConfirmed(_id txID, inclusionBlock eth.BlockID) {
 	id := _id.String()
 	if channel, ok := s.txChannels[id]; ok {
 		delete(s.txChannels, id)
-		if timedOut := channel.TxConfirmed(id, inclusionBlock); timedOut {
+		// Apply the confirmation to the channel itself first, so the
+		// FullySubmitted flag on the WAL event below reflects whether this
+		// was the channel's last outstanding tx, not just any tx.
+		timedOut := channel.TxConfirmed(id, inclusionBlock)
+		ev := WALEvent{
+			Kind:           WALTxConfirmed,
+			ChannelID:      channel.ID().String(),
+			TxID:           id,
+			Inclusion:      inclusionBlock,
+			FullySubmitted: channel.isFullySubmitted(),
+		}
+		if err := s.store.Append(ev); err != nil {
+			s.log.Error("failed to append tx-confirmed WAL event", "id", id, "err", err)
+		}
+		if timedOut {
 			s.handleChannelInvalidated(channel)
 		}
 	} else {
@@ -166,140 +405,257 @@ func (s *channelManager) handleChannelInvalidated(c *channel) {
 		}
 	}
 
-	// We want to start writing to a new channel, so reset currentChannel.
-	s.currentChannel = nil
+	// Any open channel that didn't survive the trim above can no longer
+	// accept writes either.
+	s.pruneOpenChannelsNotIn(s.channelQueue)
+	delete(s.l1OriginLastSubmittedChannel, c.ID().String())
+	delete(s.channelOpenedAt, c.ID().String())
 }
 
-// nextTxData dequeues frames from the channel and returns them encoded in a transaction.
-// It also updates the internal tx -> channels mapping
-func (s *channelManager) nextTxData(channel *channel) (txData, error) {
+// pruneOpenChannelsNotIn drops any openChannels entry that is no longer
+// present in queue, preserving relative order.
+func (s *channelManager) pruneOpenChannelsNotIn(queue []*channel) {
+	keep := make(map[*channel]bool, len(queue))
+	for _, ch := range queue {
+		keep[ch] = true
+	}
+	filtered := s.openChannels[:0]
+	for _, ch := range s.openChannels {
+		if keep[ch] {
+			filtered = append(filtered, ch)
+		}
+	}
+	s.openChannels = filtered
+}
+
+// closeOpenChannel finalizes ch's remaining frames, removes it from
+// openChannels so it no longer accepts block data, and records/logs
+// channel-closed metrics. ch remains in channelQueue until it is either
+// fully submitted or invalidated.
+func (s *channelManager) closeOpenChannel(ch *channel) {
+	for i, oc := range s.openChannels {
+		if oc == ch {
+			s.openChannels = append(s.openChannels[:i], s.openChannels[i+1:]...)
+			break
+		}
+	}
+
+	if err := ch.OutputFrames(); err != nil {
+		// AddBlock already validated everything going into this channel, so
+		// a failure here would indicate a compressor bug.
+		s.log.Error("failed to output frames for closing channel", "id", ch.ID(), "err", err)
+		return
+	}
+
+	inBytes, outBytes := ch.InputBytes(), ch.OutputBytes()
+	s.metr.RecordChannelClosed(
+		ch.ID(),
+		s.pendingBlocks(),
+		ch.TotalFrames(),
+		inBytes,
+		outBytes,
+		ch.FullErr(),
+	)
+
+	var comprRatio float64
+	if inBytes > 0 {
+		comprRatio = float64(outBytes) / float64(inBytes)
+	}
+
+	id := ch.ID().String()
+	var compressionTime time.Duration
+	if openedAt, ok := s.channelOpenedAt[id]; ok {
+		// Wall-clock time since the channel was created, as a proxy for the
+		// CPU time its compressor spent: channels aren't shared across
+		// goroutines, so this tracks actual compressor work closely enough
+		// for adaptive algorithm selection.
+		compressionTime = time.Since(openedAt)
+	}
+	s.metr.RecordCompressorObservation(ch.cfg.CompressorConfig.CompressionAlgo, comprRatio, compressionTime)
+	// Feed the same observation to cfgProvider so it can adapt its choice of
+	// CompressionAlgo for future channels, the same way it already adapts
+	// DAChoice from submission-time feedback. DAChoice() and
+	// RecordCompressorObservation() are additions to the ChannelConfigProvider
+	// interface itself, which like ChannelConfig lives in the channel config
+	// source alongside this package rather than in channel_manager.go.
+	s.cfgProvider.RecordCompressorObservation(ch.cfg.CompressorConfig.CompressionAlgo, comprRatio, compressionTime)
+	delete(s.channelOpenedAt, id)
+
+	s.log.Info("Channel closed",
+		"id", ch.ID(),
+		"blocks_pending", s.pendingBlocks(),
+		"num_frames", ch.TotalFrames(),
+		"input_bytes", inBytes,
+		"output_bytes", outBytes,
+		"oldest_l1_origin", ch.OldestL1Origin(),
+		"l1_origin", ch.LatestL1Origin(),
+		"oldest_l2", ch.OldestL2(),
+		"latest_l2", ch.LatestL2(),
+		"full_reason", ch.FullErr(),
+		"compr_ratio", comprRatio,
+		"compression_time", compressionTime,
+		"open_channels", len(s.openChannels),
+	)
+
+	if err := s.store.Append(WALEvent{Kind: WALChannelClosed, ChannelID: id}); err != nil {
+		s.log.Error("failed to append channel-closed WAL event", "id", ch.ID(), "err", err)
+	}
+}
+
+// nextTxData dequeues frames from the channel and returns them encoded in a
+// transaction for the given DA choice. It also updates the internal tx ->
+// channels mapping. Frames themselves are DA-agnostic; da only decides how
+// nextTxData packs them (blobs vs a single calldata frame), so switching DA
+// type between calls never requires rebuilding or requeueing the channel.
+func (s *channelManager) nextTxData(channel *channel, da DAChoice) (txData, error) {
 	if channel == nil || !channel.HasTxData() {
 		s.log.Trace("no next tx data")
 		return txData{}, io.EOF // TODO: not enough data error instead
 	}
-	tx := channel.NextTxData()
-
-	// update s.l1OriginLastSubmittedChannel so that the next
-	// channel's duration timeout will trigger properly
-	if channel.LatestL1Origin().Number > s.l1OriginLastSubmittedChannel.Number {
-		s.l1OriginLastSubmittedChannel = channel.LatestL1Origin()
+	tx := channel.NextTxData(da)
+
+	// update l1OriginLastSubmittedChannel for this channel so that the next
+	// channel created in its place will have its duration timeout trigger
+	// properly
+	id := channel.ID().String()
+	if channel.LatestL1Origin().Number > s.l1OriginLastSubmittedChannel[id].Number {
+		s.l1OriginLastSubmittedChannel[id] = channel.LatestL1Origin()
 	}
 	s.txChannels[tx.ID().String()] = channel
 	return tx, nil
 }
 
+// latestSubmittedL1Origin returns the highest L1 origin observed across
+// either a still-tracked channel or the Clear-provided baseline, for use as
+// the duration-timeout starting point of a newly created channel.
+func (s *channelManager) latestSubmittedL1Origin() eth.BlockID {
+	latest := s.l1OriginBaseline
+	for _, origin := range s.l1OriginLastSubmittedChannel {
+		if origin.Number > latest.Number {
+			latest = origin
+		}
+	}
+	return latest
+}
+
 // TxData returns the next tx data that should be submitted to L1.
 //
 // If the current channel is
 // full, it only returns the remaining frames of this channel until it got
 // successfully fully sent to L1. It returns io.EOF if there's no pending tx data.
 //
-// It will decide whether to switch DA type automatically.
-// When switching DA type, the channelManager state will be rebuilt
-// with a new ChannelConfig.
+// The DA type (blobs vs calldata) is decided here, at submission time,
+// rather than when the channel was built: channels produce DA-agnostic
+// frames, and cfgProvider.DAChoice is consulted fresh on every call so the
+// cheaper DA type can be picked without rewinding the block cursor or
+// rebuilding any channel.
+//
+// Every other ChannelConfig field (MaxFrameSize, TargetNumFrames,
+// CompressorConfig, MaxConcurrentChannels, MaxPendingBlocks,
+// ThrottleThreshold, ...) is reassessed here too, same as before DA type
+// moved off this path: defaultCfg is refreshed from cfgProvider on every
+// call so live tuning of those knobs (and the adaptive compression choice
+// fed by RecordCompressorObservation) takes effect without needing a DA-style rebuild, since
+// only MaxConcurrentChannels/frame-size settings actually affect channels
+// still being built, not ones already closed.
 func (s *channelManager) TxData(l1Head eth.BlockID) (txData, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.defaultCfg = s.cfgProvider.ChannelConfig()
 	channel, err := s.getReadyChannel(l1Head)
 	if err != nil {
 		return emptyTxData, err
 	}
-	// If the channel has already started being submitted,
-	// return now and ensure no requeueing happens
-	if !channel.NoneSubmitted() {
-		return s.nextTxData(channel)
-	}
-
-	// Call provider method to reassess optimal DA type
-	newCfg := s.cfgProvider.ChannelConfig()
-
-	// No change:
-	if newCfg.UseBlobs == s.defaultCfg.UseBlobs {
-		s.log.Debug("Recomputing optimal ChannelConfig: no need to switch DA type",
-			"useBlobs", s.defaultCfg.UseBlobs)
-		return s.nextTxData(channel)
-	}
-
-	// Change:
-	s.log.Info("Recomputing optimal ChannelConfig: changing DA type and requeing blocks...",
-		"useBlobsBefore", s.defaultCfg.UseBlobs,
-		"useBlobsAfter", newCfg.UseBlobs)
-
-	// Invalidate the channel so its blocks
-	// get requeued:
-	s.handleChannelInvalidated(channel)
-
-	// Set the defaultCfg so new channels
-	// pick up the new ChannelConfig
-	s.defaultCfg = newCfg
-
-	// Try again to get data to send on chain.
-	channel, err = s.getReadyChannel(l1Head)
-	if err != nil {
-		return emptyTxData, err
-	}
-	return s.nextTxData(channel)
+	da := s.cfgProvider.DAChoice()
+	return s.nextTxData(channel, da)
 }
 
 // getReadyChannel returns the next channel ready to submit data, or an error.
-// It will create a new channel if necessary.
+// It will create new channels if necessary, up to cfg.MaxConcurrentChannels.
 // If there is no data ready to send, it adds blocks from the block queue
-// to the current channel and generates frames for it.
+// to the open channels and generates frames for them.
 // Always returns nil and the io.EOF sentinel error when
 // there is no channel with txData
 func (s *channelManager) getReadyChannel(l1Head eth.BlockID) (*channel, error) {
-	var firstWithTxData *channel
-	for _, ch := range s.channelQueue {
-		if ch.HasTxData() {
-			firstWithTxData = ch
-			break
-		}
+	if ch := s.firstWithTxData(); ch != nil {
+		s.log.Debug("Requested tx data", "l1Head", l1Head, "txdata_pending", true, "blocks_pending", s.blocks.Len())
+		// Short circuit if there is pending tx data, rather than adding more
+		// blocks to the open channels first. This always takes priority, so
+		// in particular it means that while throttled, IsFull's backpressure
+		// signal gives the driver loop room to keep draining already-ready
+		// channels instead of stalling on full ones.
+		return ch, nil
 	}
+	s.log.Debug("Requested tx data", "l1Head", l1Head, "txdata_pending", false, "blocks_pending", s.blocks.Len())
 
-	dataPending := firstWithTxData != nil
-	s.log.Debug("Requested tx data", "l1Head", l1Head, "txdata_pending", dataPending, "blocks_pending", s.blocks.Len())
-
-	// Short circuit if there is pending tx data or the channel manager is closed
-	if dataPending {
-		return firstWithTxData, nil
-	}
-
-	// No pending tx data, so we have to add new blocks to the channel
+	// No pending tx data, so we have to add new blocks to the open channels
 	// If we have no saved blocks, we will not be able to create valid frames
 	if s.pendingBlocks() == 0 {
 		return nil, io.EOF
 	}
 
-	if err := s.ensureChannelWithSpace(l1Head); err != nil {
-		return nil, err
-	}
-
-	if err := s.processBlocks(); err != nil {
+	if err := s.processBlocks(l1Head); err != nil {
 		return nil, err
 	}
 
 	// Register current L1 head only after all pending blocks have been
-	// processed. Even if a timeout will be triggered now, it is better to have
-	// all pending blocks be included in this channel for submission.
-	s.registerL1Block(l1Head)
+	// processed, against a snapshot of the channels still open. Even if a
+	// timeout triggers now, it is better to have all pending blocks
+	// included in their channel for submission.
+	openChannels := append([]*channel(nil), s.openChannels...)
+	for _, ch := range openChannels {
+		s.registerL1Block(ch, l1Head)
+	}
 
 	if err := s.outputFrames(); err != nil {
 		return nil, err
 	}
 
-	if s.currentChannel.HasTxData() {
-		return s.currentChannel, nil
+	if ch := s.firstWithTxData(); ch != nil {
+		return ch, nil
 	}
 
 	return nil, io.EOF
 }
 
-// ensureChannelWithSpace ensures currentChannel is populated with a channel that has
-// space for more data (i.e. channel.IsFull returns false). If currentChannel is nil
-// or full, a new channel is created.
-func (s *channelManager) ensureChannelWithSpace(l1Head eth.BlockID) error {
-	if s.currentChannel != nil && !s.currentChannel.IsFull() {
-		return nil
+// firstWithTxData returns the oldest channel in channelQueue that has frame
+// data ready to submit, or nil if none do.
+func (s *channelManager) firstWithTxData() *channel {
+	for _, ch := range s.channelQueue {
+		if ch.HasTxData() {
+			return ch
+		}
+	}
+	return nil
+}
+
+// ensureChannelWithSpace returns an open channel that has space for more
+// data (i.e. channel.IsFull returns false), creating one if none of the
+// currently open channels qualify. Up to cfg.MaxConcurrentChannels channels
+// may be open at once; once that limit is reached, nil is returned so the
+// caller can wait for one of them to close.
+//
+// Candidates are tried starting from nextChannelIdx, which advances by one
+// on every call that returns an existing channel, so consecutive blocks
+// stripe round-robin across all open channels instead of filling the first
+// one before ever touching the others.
+func (s *channelManager) ensureChannelWithSpace(l1Head eth.BlockID) (*channel, error) {
+	if n := len(s.openChannels); n > 0 {
+		for i := 0; i < n; i++ {
+			idx := (s.nextChannelIdx + i) % n
+			if ch := s.openChannels[idx]; !ch.IsFull() {
+				s.nextChannelIdx = (idx + 1) % n
+				return ch, nil
+			}
+		}
+	}
+
+	maxConcurrent := s.defaultCfg.MaxConcurrentChannels
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	if len(s.openChannels) >= maxConcurrent {
+		return nil, nil
 	}
 
 	// We reuse the ChannelConfig from the last channel.
@@ -309,70 +665,99 @@ func (s *channelManager) ensureChannelWithSpace(l1Head eth.BlockID) error {
 
 	channelOut, err := s.outFactory(cfg, s.rollupCfg)
 	if err != nil {
-		return fmt.Errorf("creating channel out: %w", err)
+		return nil, fmt.Errorf("creating channel out: %w", err)
 	}
 
-	pc := newChannel(s.log, s.metr, cfg, s.rollupCfg, s.l1OriginLastSubmittedChannel.Number, channelOut)
+	l1OriginBaseline := s.latestSubmittedL1Origin()
+	pc := newChannel(s.log, s.metr, cfg, s.rollupCfg, l1OriginBaseline.Number, channelOut)
 
-	s.currentChannel = pc
+	s.openChannels = append(s.openChannels, pc)
 	s.channelQueue = append(s.channelQueue, pc)
 
 	s.log.Info("Created channel",
 		"id", pc.ID(),
 		"l1Head", l1Head,
 		"blocks_pending", s.pendingBlocks(),
-		"l1OriginLastSubmittedChannel", s.l1OriginLastSubmittedChannel,
+		"l1OriginLastSubmittedChannel", l1OriginBaseline,
 		"batch_type", cfg.BatchType,
 		"compression_algo", cfg.CompressorConfig.CompressionAlgo,
 		"target_num_frames", cfg.TargetNumFrames,
 		"max_frame_size", cfg.MaxFrameSize,
-		"use_blobs", cfg.UseBlobs,
+		"open_channels", len(s.openChannels),
 	)
 	s.metr.RecordChannelOpened(pc.ID(), s.blocks.Len())
+	s.channelOpenedAt[pc.ID().String()] = time.Now()
 
-	return nil
+	if err := s.store.Append(WALEvent{Kind: WALChannelOpened, ChannelID: pc.ID().String()}); err != nil {
+		s.log.Error("failed to append channel-opened WAL event", "id", pc.ID(), "err", err)
+	}
+
+	return pc, nil
 }
 
-// registerL1Block registers the given block at the current channel.
-func (s *channelManager) registerL1Block(l1Head eth.BlockID) {
-	s.currentChannel.CheckTimeout(l1Head.Number)
+// registerL1Block registers the given L1 head at ch, and closes ch if doing
+// so caused it to time out.
+func (s *channelManager) registerL1Block(ch *channel, l1Head eth.BlockID) {
+	ch.CheckTimeout(l1Head.Number)
 	s.log.Debug("new L1-block registered at channel builder",
+		"id", ch.ID(),
 		"l1Head", l1Head,
-		"channel_full", s.currentChannel.IsFull(),
-		"full_reason", s.currentChannel.FullErr(),
+		"channel_full", ch.IsFull(),
+		"full_reason", ch.FullErr(),
 	)
+	if ch.IsFull() {
+		s.closeOpenChannel(ch)
+	}
 }
 
-// processBlocks adds blocks from the blocks queue to the current channel until
-// either the queue got exhausted or the channel is full.
-func (s *channelManager) processBlocks() error {
+// processBlocks adds blocks from the blocks queue to the open channels,
+// striping each block across whichever open channel currently has space
+// (round-robin, opening additional channels up to MaxConcurrentChannels as
+// existing ones fill up), until either the block queue is exhausted or no
+// more channels can be opened.
+func (s *channelManager) processBlocks(l1Head eth.BlockID) error {
 	var (
 		blocksAdded int
 		_chFullErr  *ChannelFullError // throw away, just for type checking
 		latestL2ref eth.L2BlockRef
 	)
 
-	for i := s.blockCursor; ; i++ {
+	for i := s.blockCursor; ; {
 		block, ok := s.blocks.PeekN(i)
 		if !ok {
 			break
 		}
 
-		l1info, err := s.currentChannel.AddBlock(block)
-		if errors.As(err, &_chFullErr) {
-			// current block didn't get added because channel is already full
+		ch, err := s.ensureChannelWithSpace(l1Head)
+		if err != nil {
+			return err
+		}
+		if ch == nil {
+			// MaxConcurrentChannels are all open and full; wait for one to
+			// be submitted before accepting more blocks.
 			break
+		}
+
+		l1info, err := ch.AddBlock(block)
+		if errors.As(err, &_chFullErr) {
+			// ch just became full without accepting the block; close it and
+			// retry the same block against another (possibly new) channel.
+			s.closeOpenChannel(ch)
+			continue
 		} else if err != nil {
 			return fmt.Errorf("adding block[%d] to channel builder: %w", i, err)
 		}
-		s.log.Debug("Added block to channel", "id", s.currentChannel.ID(), "block", eth.ToBlockID(block))
+		s.log.Debug("Added block to channel", "id", ch.ID(), "block", eth.ToBlockID(block))
+		if err := s.store.Append(WALEvent{Kind: WALBlockConsumed, ChannelID: ch.ID().String()}); err != nil {
+			s.log.Error("failed to append block-consumed WAL event", "id", ch.ID(), "err", err)
+		}
 
 		blocksAdded += 1
+		i++
 		latestL2ref = l2BlockRefFromBlockAndL1Info(block, l1info)
 		s.metr.RecordL2BlockInChannel(block)
-		// current block got added but channel is now full
-		if s.currentChannel.IsFull() {
-			break
+		if ch.IsFull() {
+			s.closeOpenChannel(ch)
 		}
 	}
 
@@ -381,55 +766,42 @@ func (s *channelManager) processBlocks() error {
 	s.metr.RecordL2BlocksAdded(latestL2ref,
 		blocksAdded,
 		s.blocks.Len(),
-		s.currentChannel.InputBytes(),
-		s.currentChannel.ReadyBytes())
-	s.log.Debug("Added blocks to channel",
+		s.openChannelsInputBytes(),
+		s.openChannelsReadyBytes())
+	s.log.Debug("Added blocks to channels",
 		"blocks_added", blocksAdded,
 		"blocks_pending", s.pendingBlocks(),
-		"channel_full", s.currentChannel.IsFull(),
-		"input_bytes", s.currentChannel.InputBytes(),
-		"ready_bytes", s.currentChannel.ReadyBytes(),
+		"open_channels", len(s.openChannels),
 	)
 	return nil
 }
 
-// outputFrames generates frames for the current channel, and computes and logs the compression ratio
-func (s *channelManager) outputFrames() error {
-	if err := s.currentChannel.OutputFrames(); err != nil {
-		return fmt.Errorf("creating frames with channel builder: %w", err)
-	}
-	if !s.currentChannel.IsFull() {
-		return nil
+func (s *channelManager) openChannelsInputBytes() (total uint64) {
+	for _, ch := range s.openChannels {
+		total += ch.InputBytes()
 	}
+	return total
+}
 
-	inBytes, outBytes := s.currentChannel.InputBytes(), s.currentChannel.OutputBytes()
-	s.metr.RecordChannelClosed(
-		s.currentChannel.ID(),
-		s.pendingBlocks(),
-		s.currentChannel.TotalFrames(),
-		inBytes,
-		outBytes,
-		s.currentChannel.FullErr(),
-	)
-
-	var comprRatio float64
-	if inBytes > 0 {
-		comprRatio = float64(outBytes) / float64(inBytes)
+func (s *channelManager) openChannelsReadyBytes() (total uint64) {
+	for _, ch := range s.openChannels {
+		total += ch.ReadyBytes()
 	}
+	return total
+}
 
-	s.log.Info("Channel closed",
-		"id", s.currentChannel.ID(),
-		"blocks_pending", s.pendingBlocks(),
-		"num_frames", s.currentChannel.TotalFrames(),
-		"input_bytes", inBytes,
-		"output_bytes", outBytes,
-		"oldest_l1_origin", s.currentChannel.OldestL1Origin(),
-		"l1_origin", s.currentChannel.LatestL1Origin(),
-		"oldest_l2", s.currentChannel.OldestL2(),
-		"latest_l2", s.currentChannel.LatestL2(),
-		"full_reason", s.currentChannel.FullErr(),
-		"compr_ratio", comprRatio,
-	)
+// outputFrames flushes any newly-ready frames for channels that are still
+// open (i.e. still accepting block data). Channels that filled up this
+// round are finalized eagerly by closeOpenChannel instead.
+func (s *channelManager) outputFrames() error {
+	for _, ch := range s.openChannels {
+		if err := ch.OutputFrames(); err != nil {
+			return fmt.Errorf("creating frames with channel builder: %w", err)
+		}
+		if err := s.store.Append(WALEvent{Kind: WALFramesEmitted, ChannelID: ch.ID().String()}); err != nil {
+			s.log.Error("failed to append frames-emitted WAL event", "id", ch.ID(), "err", err)
+		}
+	}
 	return nil
 }
 
@@ -448,6 +820,19 @@ func (s *channelManager) AddL2Block(block *types.Block) error {
 	s.blocks.Enqueue(block)
 	s.tip = block.Hash()
 
+	ev, err := newBlockAddedEvent(block)
+	if err != nil {
+		// Same reasoning as the Append error below: log rather than fail,
+		// since losing this WAL entry only means a future restart may
+		// re-derive the block from the sequencer via Clear instead of the WAL.
+		s.log.Error("failed to RLP-encode block for WAL", "block", eth.ToBlockID(block), "err", err)
+	} else if err := s.store.Append(ev); err != nil {
+		// The block is already durably held in the sequencer itself; losing
+		// this WAL entry only means a future restart may re-derive it from
+		// there via Clear, so we log rather than fail the call.
+		s.log.Error("failed to append block-added WAL event", "block", eth.ToBlockID(block), "err", err)
+	}
+
 	return nil
 }
 
@@ -464,9 +849,17 @@ func l2BlockRefFromBlockAndL1Info(block *types.Block, l1info *derive.L1BlockInfo
 
 var ErrPendingAfterClose = errors.New("pending channels remain after closing channel-manager")
 
-// pruneSafeBlocks dequeues blocks from the internal blocks queue
-// if they have now become safe.
-func (s *channelManager) pruneSafeBlocks(newSafeHead eth.L2BlockRef) {
+// pruneSafeBlocks dequeues blocks from the internal blocks queue once they
+// become safe. newSafeChain is the tail of the safe chain, oldest-first,
+// ending at the new safe head; supplying enough of it lets pruneSafeBlocks
+// find a common ancestor on a reorg (see handleSafeChainReorg) instead of
+// discarding all local state as it used to.
+func (s *channelManager) pruneSafeBlocks(newSafeChain []eth.L2BlockRef) {
+	if len(newSafeChain) == 0 {
+		return
+	}
+	newSafeHead := newSafeChain[len(newSafeChain)-1]
+
 	oldestBlock, ok := s.blocks.Peek()
 	if !ok {
 		// no blocks to prune
@@ -505,12 +898,7 @@ func (s *channelManager) pruneSafeBlocks(newSafeHead eth.L2BlockRef) {
 	}
 
 	if s.blocks[numBlocksToDequeue-1].Hash() != newSafeHead.Hash {
-		s.log.Warn("safe chain reorg, clearing channel manager state",
-			"existingBlock", eth.ToBlockID(s.blocks[numBlocksToDequeue-1]),
-			"newSafeBlock", newSafeHead)
-		// We should restart work from the new safe head,
-		// and therefore prune all the blocks.
-		s.Clear(newSafeHead.L1Origin)
+		s.handleSafeChainReorg(newSafeChain)
 		return
 	}
 
@@ -522,6 +910,80 @@ func (s *channelManager) pruneSafeBlocks(newSafeHead eth.L2BlockRef) {
 	if s.blockCursor < 0 {
 		panic("negative blockCursor")
 	}
+
+	s.compactStore(int(numBlocksToDequeue))
+}
+
+// handleSafeChainReorg is called when the safe chain no longer matches our
+// local block queue. Rather than discarding all local state (as a plain
+// Clear would, causing a full re-fetch from the sequencer on every small
+// reorg), it finds the common ancestor between the local queue and
+// newSafeChain, keeps everything built on that ancestor, and only
+// invalidates the channels built on the divergent suffix.
+func (s *channelManager) handleSafeChainReorg(newSafeChain []eth.L2BlockRef) {
+	newSafeHead := newSafeChain[len(newSafeChain)-1]
+
+	byNumber := make(map[uint64]eth.L2BlockRef, len(newSafeChain))
+	for _, b := range newSafeChain {
+		byNumber[b.Number] = b
+	}
+
+	// Walk the local queue from the oldest block, tracking the highest
+	// index whose hash still matches the corresponding (by block number)
+	// entry of the new safe chain.
+	ancestorIdx := -1
+	for i := 0; i < s.blocks.Len(); i++ {
+		safeBlock, ok := byNumber[s.blocks[i].NumberU64()]
+		if !ok || safeBlock.Hash != s.blocks[i].Hash() {
+			break
+		}
+		ancestorIdx = i
+	}
+
+	if ancestorIdx < 0 {
+		// The reorg goes back further than the safe-chain history we were
+		// given; there's nothing valid left to preserve.
+		s.log.Warn("safe chain reorg exceeds supplied history, clearing channel manager state",
+			"newSafeBlock", newSafeHead)
+		s.Clear(newSafeHead.L1Origin)
+		return
+	}
+
+	ancestorBlock := s.blocks[ancestorIdx]
+	s.log.Warn("safe chain reorg, rewinding to common ancestor instead of clearing",
+		"ancestor", eth.ToBlockID(ancestorBlock),
+		"newSafeBlock", newSafeHead)
+
+	// Invalidate the oldest channel built past the ancestor: its blocks
+	// belong to the abandoned fork and can't simply be rebuilt, they need
+	// replacing with the canonical chain once the driver re-polls it.
+	// handleChannelInvalidated trims channelQueue from that channel
+	// onwards, so this also takes care of every newer divergent channel.
+	for _, ch := range s.channelQueue {
+		// LatestL2, not OldestL2: a channel spans many blocks, and one that
+		// straddles the fork point (OldestL2 <= ancestor < LatestL2) still
+		// holds forked blocks in its tail even though its head predates the
+		// fork, so it must be invalidated too. Only a channel entirely built
+		// on the ancestor or earlier (LatestL2 <= ancestor) is untouched.
+		if ch.LatestL2().Number > ancestorBlock.NumberU64() {
+			s.handleChannelInvalidated(ch)
+			break
+		}
+	}
+
+	// Drop the local block queue entirely: the prefix up to the ancestor is
+	// now safe and about to leave the queue anyway, and the suffix past it
+	// is the abandoned fork. Reset tip to the ancestor so the next
+	// AddL2Block (for the canonical continuation) passes its parent-hash
+	// check.
+	droppedBlocks := s.blocks.Len()
+	if _, err := s.blocks.DequeueN(droppedBlocks); err != nil {
+		panic(fmt.Sprintf("failed to drop blocks after safe chain reorg: %v", err))
+	}
+	s.blockCursor = 0
+	s.tip = ancestorBlock.Hash()
+
+	s.compactStore(droppedBlocks)
 }
 
 // pruneChannels dequeues channels from the internal channels queue
@@ -532,9 +994,40 @@ func (s *channelManager) pruneChannels(newSafeHead eth.L2BlockRef) {
 		if ch.LatestL2().Number > newSafeHead.Number {
 			break
 		}
+		// This channel is now fully safe and leaving the queue for good; fold
+		// its L1 origin into l1OriginBaseline so latestSubmittedL1Origin still
+		// accounts for it, then drop its entry instead of leaking it for the
+		// life of the process.
+		id := ch.ID().String()
+		if origin := s.l1OriginLastSubmittedChannel[id]; origin.Number > s.l1OriginBaseline.Number {
+			s.l1OriginBaseline = origin
+		}
+		delete(s.l1OriginLastSubmittedChannel, id)
 		i++
 	}
 	s.channelQueue = s.channelQueue[i:]
+
+	s.compactStore(0)
+}
+
+// compactStore discards WAL entries that can no longer affect a future
+// Recover, now that channelQueue has advanced and/or newlySafeBlocks more
+// blocks have been dequeued from the front of s.blocks.
+//
+// newlySafeBlocks must be the count of blocks actually dequeued from the
+// front of s.blocks since the previous compaction (0 if none were), NOT
+// s.blockCursor: blockCursor also advances for blocks merely consumed into a
+// still-open or still-unconfirmed channel, long before pruneSafeBlocks ever
+// dequeues them, so passing it here would make Compact discard WAL records
+// for blocks that are still sitting in s.blocks waiting to become safe.
+func (s *channelManager) compactStore(newlySafeBlocks int) {
+	liveChannelIDs := make([]string, 0, len(s.channelQueue))
+	for _, ch := range s.channelQueue {
+		liveChannelIDs = append(liveChannelIDs, ch.ID().String())
+	}
+	if err := s.store.Compact(newlySafeBlocks, liveChannelIDs); err != nil {
+		s.log.Error("failed to compact channel store", "err", err)
+	}
 }
 
 // PendingDABytes returns the current number of bytes pending to be written to the DA layer (from blocks fetched from L2
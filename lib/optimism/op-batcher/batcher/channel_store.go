@@ -0,0 +1,269 @@
+package batcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// WALEventKind identifies the kind of channelManager state change recorded
+// in a WALEvent.
+type WALEventKind int
+
+const (
+	WALBlockAdded WALEventKind = iota
+	// WALBlockConsumed records that a block already durably held via a
+	// prior WALBlockAdded was added to the channel named by ChannelID. It's
+	// what lets Recover tell which blocks are already inside a channel
+	// (and so must not be fed into a freshly rebuilt one) instead of only
+	// knowing the raw block queue.
+	WALBlockConsumed
+	WALChannelOpened
+	WALFramesEmitted
+	WALChannelClosed
+	WALTxConfirmed
+	WALTxFailed
+)
+
+// WALEvent is a single durable record of a channelManager state change.
+// Only the fields relevant to Kind are populated.
+type WALEvent struct {
+	Kind WALEventKind
+
+	// BlockRLP is the RLP encoding of the block (WALBlockAdded). types.Block
+	// keeps its header/transactions/uncles unexported, so encoding/json
+	// can't see them; RLP is the format the block actually round-trips
+	// through, matching how compressor_bench.go encodes blocks as benchmark
+	// input.
+	BlockRLP  []byte `json:",omitempty"` // WALBlockAdded
+	ChannelID string `json:",omitempty"` // every kind except WALBlockAdded
+
+	TxID      string      `json:",omitempty"` // WALTxConfirmed, WALTxFailed
+	Inclusion eth.BlockID `json:",omitempty"` // WALTxConfirmed
+	// FullySubmitted is channel.isFullySubmitted() as of this WALTxConfirmed
+	// event. A channel can need more than one tx (see txChannels), so a
+	// single WALTxConfirmed does not by itself mean the channel's data is
+	// durably on L1 — Recover relies on this flag, not the mere presence of
+	// a WALTxConfirmed record, to know a channel's blocks are all safely
+	// submitted and can be skipped on replay.
+	FullySubmitted bool `json:",omitempty"` // WALTxConfirmed
+}
+
+// newBlockAddedEvent returns a WALBlockAdded event carrying block's RLP
+// encoding.
+func newBlockAddedEvent(block *types.Block) (WALEvent, error) {
+	data, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return WALEvent{}, fmt.Errorf("RLP-encoding block %v for WAL: %w", block.Hash(), err)
+	}
+	return WALEvent{Kind: WALBlockAdded, BlockRLP: data}, nil
+}
+
+// Block decodes ev.BlockRLP back into a block. Only valid for a
+// WALBlockAdded event.
+func (ev WALEvent) Block() (*types.Block, error) {
+	var block types.Block
+	if err := rlp.DecodeBytes(ev.BlockRLP, &block); err != nil {
+		return nil, fmt.Errorf("RLP-decoding WAL block: %w", err)
+	}
+	return &block, nil
+}
+
+// ChannelStore is a write-ahead log for channelManager state, so that an
+// in-flight set of blocks and channels survives a batcher restart instead of
+// being re-derived from the sequencer via Clear. channelManager appends an
+// event on every AddL2Block, channel open/close, frame emission, and
+// TxConfirmed/TxFailed, and replays the log once at startup via Recover.
+//
+// Implementations must be safe for concurrent use; channelManager calls
+// Append while holding its own lock, so Append must not block on anything
+// that could itself wait on the channelManager.
+type ChannelStore interface {
+	// Append durably records ev. Implementations must fsync before
+	// returning for ev.Kind == WALChannelClosed, so that the channel-closed
+	// record and every event appended before it survive a crash.
+	Append(ev WALEvent) error
+	// Replay returns every event appended since the log was last
+	// compacted, in append order.
+	Replay() ([]WALEvent, error)
+	// Compact discards log entries that are no longer needed once the
+	// channel manager's state has advanced past them. safeBlockCursor is
+	// the number of WALBlockAdded records, counted from the front of the
+	// log as it currently stands, that just became safe to drop (e.g. the
+	// count pruneSafeBlocks just dequeued from the front of its block
+	// queue) — it is relative to this call, not a cumulative/absolute
+	// block index. Pass 0 when recompacting only because liveChannelIDs
+	// changed.
+	Compact(safeBlockCursor int, liveChannelIDs []string) error
+	Close() error
+}
+
+// noopChannelStore is used when no ChannelStore is configured. It preserves
+// the pre-WAL behavior of rebuilding all state from the sequencer on
+// restart.
+type noopChannelStore struct{}
+
+func (noopChannelStore) Append(WALEvent) error       { return nil }
+func (noopChannelStore) Replay() ([]WALEvent, error) { return nil, nil }
+func (noopChannelStore) Compact(int, []string) error { return nil }
+func (noopChannelStore) Close() error                { return nil }
+
+// fileChannelStore is the default ChannelStore: an append-only, newline
+// delimited JSON log. It's simple and crash-safe (given the fsync on
+// channel-closed records below), at the cost of O(log size) replay on
+// startup; callers wanting better startup latency on a large backlog can
+// supply a pebble/leveldb-backed ChannelStore instead.
+type fileChannelStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileChannelStore opens (or creates) the WAL file at path.
+func NewFileChannelStore(path string) (ChannelStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening channel store %q: %w", path, err)
+	}
+	return &fileChannelStore{
+		path: path,
+		file: f,
+		enc:  json.NewEncoder(f),
+	}, nil
+}
+
+func (s *fileChannelStore) Append(ev WALEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(ev); err != nil {
+		return fmt.Errorf("appending WAL event (kind %d): %w", ev.Kind, err)
+	}
+	if ev.Kind == WALChannelClosed {
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("fsyncing channel-closed WAL event: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *fileChannelStore) Replay() ([]WALEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to start of channel store: %w", err)
+	}
+
+	var events []WALEvent
+	dec := json.NewDecoder(s.file)
+	for {
+		var ev WALEvent
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("replaying channel store: %w", err)
+		}
+		events = append(events, ev)
+	}
+
+	if _, err := s.file.Seek(0, io.SeekEnd); err != nil {
+		return nil, fmt.Errorf("seeking to end of channel store: %w", err)
+	}
+	return events, nil
+}
+
+// Compact rewrites the WAL to drop the first safeBlockCursor WALBlockAdded
+// events, and every event carrying a ChannelID (WALBlockConsumed,
+// WALChannelOpened, WALFramesEmitted, WALChannelClosed, WALTxConfirmed,
+// WALTxFailed) whose channel is not in liveChannelIDs.
+func (s *fileChannelStore) Compact(safeBlockCursor int, liveChannelIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	live := make(map[string]bool, len(liveChannelIDs))
+	for _, id := range liveChannelIDs {
+		live[id] = true
+	}
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking to start of channel store: %w", err)
+	}
+	dec := json.NewDecoder(s.file)
+	var kept []WALEvent
+	// blockIdx counts WALBlockAdded events only, since safeBlockCursor is an
+	// index into the block queue, not into the interleaved event stream —
+	// comparing it against a position that also advances on channel/tx
+	// events would let already-safe blocks survive compaction indefinitely.
+	blockIdx := 0
+	for {
+		var ev WALEvent
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("reading channel store for compaction: %w", err)
+		}
+		switch ev.Kind {
+		case WALBlockAdded:
+			idx := blockIdx
+			blockIdx++
+			if idx < safeBlockCursor {
+				continue
+			}
+		case WALBlockConsumed, WALChannelOpened, WALFramesEmitted, WALChannelClosed, WALTxConfirmed, WALTxFailed:
+			if ev.ChannelID != "" && !live[ev.ChannelID] {
+				continue
+			}
+		}
+		kept = append(kept, ev)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "channel-store-compact-*")
+	if err != nil {
+		return fmt.Errorf("creating compaction temp file: %w", err)
+	}
+	enc := json.NewEncoder(tmp)
+	for _, ev := range kept {
+		if err := enc.Encode(ev); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("writing compacted channel store: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("fsyncing compacted channel store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("closing compacted channel store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("installing compacted channel store: %w", err)
+	}
+
+	s.file.Close()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopening compacted channel store: %w", err)
+	}
+	s.file = f
+	s.enc = json.NewEncoder(f)
+	return nil
+}
+
+func (s *fileChannelStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,163 @@
+package batcher
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-batcher/compressor"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// CompressorBenchRow is one measurement produced by RunCompressorBenchmark:
+// how a single registered algorithm did against the replayed block data at a
+// given TargetNumFrames/MaxFrameSize.
+type CompressorBenchRow struct {
+	Algo             compressor.CompressionAlgo
+	TargetNumFrames  int
+	MaxFrameSize     uint64
+	InputBytes       int
+	OutputBytes      int
+	CompressionRatio float64
+	Duration         time.Duration
+}
+
+// RunCompressorBenchmark replays blocks (see LoadBenchmarkBlocks) through
+// every algorithm registered in reg, at each of the given channel configs,
+// and writes one CSV row per (algorithm, config) pair to w. It's meant to be
+// run offline against a sample of real L2 blocks so operators can tune
+// TargetNumFrames/MaxFrameSize and compare compression algorithms against
+// real traffic before deploying either change.
+func RunCompressorBenchmark(w io.Writer, reg *CompressorRegistry, blocks []*types.Block, configs []ChannelConfig) error {
+	blockData, err := encodeBenchmarkBlocks(blocks)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"algo", "target_num_frames", "max_frame_size", "input_bytes", "output_bytes", "compression_ratio", "duration_ms"}); err != nil {
+		return fmt.Errorf("writing benchmark CSV header: %w", err)
+	}
+
+	for _, cfg := range configs {
+		for algo := range reg.factories {
+			row, err := benchmarkOne(reg, algo, cfg, blockData)
+			if err != nil {
+				return fmt.Errorf("benchmarking %q at target_num_frames=%d: %w", algo, cfg.TargetNumFrames, err)
+			}
+			if err := cw.Write([]string{
+				string(row.Algo),
+				strconv.Itoa(row.TargetNumFrames),
+				strconv.FormatUint(row.MaxFrameSize, 10),
+				strconv.Itoa(row.InputBytes),
+				strconv.Itoa(row.OutputBytes),
+				strconv.FormatFloat(row.CompressionRatio, 'f', 4, 64),
+				strconv.FormatInt(row.Duration.Milliseconds(), 10),
+			}); err != nil {
+				return fmt.Errorf("writing benchmark CSV row: %w", err)
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// LoadBenchmarkBlocks replays store's WAL and returns every block recorded
+// via a WALBlockAdded event, in append order, for use as RunCompressorBenchmark's
+// input. Pointing it at a copy of a running batcher's WAL file (see
+// NewFileChannelStore) is how an operator benchmarks against real traffic
+// instead of synthetic blocks.
+func LoadBenchmarkBlocks(store ChannelStore) ([]*types.Block, error) {
+	events, err := store.Replay()
+	if err != nil {
+		return nil, fmt.Errorf("replaying channel store for benchmark: %w", err)
+	}
+	var blocks []*types.Block
+	for _, ev := range events {
+		if ev.Kind != WALBlockAdded {
+			continue
+		}
+		block, err := ev.Block()
+		if err != nil {
+			return nil, fmt.Errorf("decoding WAL block for benchmark: %w", err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// RunCompressorBenchmarkFromWAL is the runnable entrypoint for
+// RunCompressorBenchmark: it opens the ChannelStore WAL at walPath, extracts
+// the recorded blocks via LoadBenchmarkBlocks, and benchmarks them. An
+// operator invokes this directly against a copy of a batcher's WAL file;
+// nothing else needs to be wired up first.
+func RunCompressorBenchmarkFromWAL(w io.Writer, reg *CompressorRegistry, walPath string, configs []ChannelConfig) error {
+	store, err := NewFileChannelStore(walPath)
+	if err != nil {
+		return fmt.Errorf("opening WAL %q for benchmark: %w", walPath, err)
+	}
+	defer store.Close()
+
+	blocks, err := LoadBenchmarkBlocks(store)
+	if err != nil {
+		return err
+	}
+	return RunCompressorBenchmark(w, reg, blocks, configs)
+}
+
+func encodeBenchmarkBlocks(blocks []*types.Block) ([][]byte, error) {
+	encoded := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		data, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			return nil, fmt.Errorf("encoding block %v for benchmark: %w", block.Hash(), err)
+		}
+		encoded[i] = data
+	}
+	return encoded, nil
+}
+
+// benchmarkOne runs a single algorithm over blockData under cfg's frame
+// settings. Duration is wall-clock time spent writing and closing the
+// compressor, used the same way channelManager uses channelOpenedAt: as a
+// CPU-time proxy, not a precise measurement.
+func benchmarkOne(reg *CompressorRegistry, algo compressor.CompressionAlgo, cfg ChannelConfig, blockData [][]byte) (CompressorBenchRow, error) {
+	compCfg := cfg.CompressorConfig
+	compCfg.CompressionAlgo = algo
+	c, err := reg.New(compCfg)
+	if err != nil {
+		return CompressorBenchRow{}, fmt.Errorf("constructing compressor: %w", err)
+	}
+
+	start := time.Now()
+	var inBytes int
+	for _, data := range blockData {
+		inBytes += len(data)
+		if _, err := c.Write(data); err != nil {
+			return CompressorBenchRow{}, fmt.Errorf("writing block data: %w", err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		return CompressorBenchRow{}, fmt.Errorf("closing compressor: %w", err)
+	}
+	elapsed := time.Since(start)
+
+	outBytes := c.Len()
+	var ratio float64
+	if inBytes > 0 {
+		ratio = float64(outBytes) / float64(inBytes)
+	}
+	return CompressorBenchRow{
+		Algo:             algo,
+		TargetNumFrames:  cfg.TargetNumFrames,
+		MaxFrameSize:     cfg.MaxFrameSize,
+		InputBytes:       inBytes,
+		OutputBytes:      outBytes,
+		CompressionRatio: ratio,
+		Duration:         elapsed,
+	}, nil
+}
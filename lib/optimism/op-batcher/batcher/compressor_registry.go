@@ -0,0 +1,191 @@
+package batcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-batcher/compressor"
+	"github.com/ethereum-optimism/optimism/op-batcher/metrics"
+)
+
+// CompressorRegistry maps a compressor.CompressionAlgo to the factory that
+// builds it, so outFactory can pick an algorithm at channel-creation time
+// based on ChannelConfig.CompressorConfig.CompressionAlgo instead of being
+// hardcoded to whatever compressor.NewCompressor happens to select. The
+// zero value is not usable; construct one with NewCompressorRegistry.
+type CompressorRegistry struct {
+	metr      metrics.Metricer
+	factories map[compressor.CompressionAlgo]func(compressor.Config) (compressor.Compressor, error)
+}
+
+// NewCompressorRegistry returns a registry pre-populated with the built-in
+// zlib, brotli and zstd algorithms. metr receives the per-candidate
+// compression ratio and CPU-time observations that shadow-mode compressors
+// record when they pick a winner.
+func NewCompressorRegistry(metr metrics.Metricer) *CompressorRegistry {
+	r := &CompressorRegistry{
+		metr:      metr,
+		factories: make(map[compressor.CompressionAlgo]func(compressor.Config) (compressor.Compressor, error)),
+	}
+	r.Register(compressor.Zlib, compressor.NewZlibCompressor)
+	r.Register(compressor.Brotli, compressor.NewBrotliCompressor)
+	r.Register(compressor.Zstd, compressor.NewZstdCompressor)
+	return r
+}
+
+// Register adds or replaces the factory used for algo.
+func (r *CompressorRegistry) Register(algo compressor.CompressionAlgo, factory func(compressor.Config) (compressor.Compressor, error)) {
+	r.factories[algo] = factory
+}
+
+// New builds the compressor configured by cfg.CompressionAlgo. If that's
+// compressor.Shadow, it instead wraps every registered non-shadow algorithm so
+// the same input is compressed by each of them in parallel and the smallest
+// output wins once the caller closes the channel; see shadowCompressor.
+func (r *CompressorRegistry) New(cfg compressor.Config) (compressor.Compressor, error) {
+	if cfg.CompressionAlgo == compressor.Shadow {
+		return r.newShadowCompressor(cfg)
+	}
+	factory, ok := r.factories[cfg.CompressionAlgo]
+	if !ok {
+		return nil, fmt.Errorf("unregistered compression algorithm %q", cfg.CompressionAlgo)
+	}
+	return factory(cfg)
+}
+
+func (r *CompressorRegistry) newShadowCompressor(cfg compressor.Config) (compressor.Compressor, error) {
+	candidates := make(map[compressor.CompressionAlgo]compressor.Compressor, len(r.factories))
+	for algo, factory := range r.factories {
+		c, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("constructing shadow candidate %q: %w", algo, err)
+		}
+		candidates[algo] = c
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("shadow mode requires at least one registered non-shadow algorithm")
+	}
+	return &shadowCompressor{registry: r, candidates: candidates}, nil
+}
+
+// shadowCompressor mirrors every Write to each candidate algorithm so they
+// all compress the same input, then on Read/Close picks whichever candidate
+// produced the smallest output. This doubles (or more) CPU time per channel
+// in exchange for always emitting the cheapest frames, and is meant for
+// operators still tuning which single algorithm to configure permanently.
+type shadowCompressor struct {
+	registry   *CompressorRegistry
+	candidates map[compressor.CompressionAlgo]compressor.Compressor
+	winner     compressor.Compressor
+	winnerAlgo compressor.CompressionAlgo
+	writeStart time.Time
+	inBytes    int
+}
+
+func (c *shadowCompressor) Write(p []byte) (int, error) {
+	if c.writeStart.IsZero() {
+		c.writeStart = time.Now()
+	}
+	n := len(p)
+	c.inBytes += n
+	for algo, candidate := range c.candidates {
+		if _, err := candidate.Write(p); err != nil {
+			return 0, fmt.Errorf("shadow candidate %q write: %w", algo, err)
+		}
+	}
+	return n, nil
+}
+
+// pickWinner settles on the candidate with the smallest output and, while
+// it's at it, reports each candidate's ratio and CPU-time proxy to metr so
+// the registry's feedback loop has per-algorithm data even though only one
+// algorithm's frames ever actually get submitted.
+func (c *shadowCompressor) pickWinner() {
+	if c.winner != nil {
+		return
+	}
+	elapsed := time.Since(c.writeStart)
+	var bestLen = -1
+	for algo, candidate := range c.candidates {
+		l := candidate.Len()
+		var ratio float64
+		if c.inBytes > 0 {
+			ratio = float64(l) / float64(c.inBytes)
+		}
+		c.registry.metr.RecordCompressorObservation(algo, ratio, elapsed)
+		if bestLen == -1 || l < bestLen {
+			bestLen = l
+			c.winner = candidate
+			c.winnerAlgo = algo
+		}
+	}
+}
+
+func (c *shadowCompressor) Read(p []byte) (int, error) {
+	c.pickWinner()
+	return c.winner.Read(p)
+}
+
+func (c *shadowCompressor) Close() error {
+	// Close every candidate before picking, so the sizes compared include the
+	// data each backend still held buffered internally.
+	var firstErr error
+	for algo, candidate := range c.candidates {
+		if err := candidate.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("closing shadow candidate %q: %w", algo, err)
+		}
+	}
+	c.pickWinner()
+	return firstErr
+}
+
+func (c *shadowCompressor) Reset() {
+	for _, candidate := range c.candidates {
+		candidate.Reset()
+	}
+	c.winner = nil
+	c.writeStart = time.Time{}
+	c.inBytes = 0
+}
+
+// currentSmallest returns whichever candidate currently has the smallest
+// compressed output, without committing to it as the final winner. Len and
+// FullErr use this while data may still be written: the channel builder
+// calls both after every Write to check fullness, well before Close, so
+// locking in a winner that early (as pickWinner does) would fix the result
+// based on a fraction of the data and could disagree with a later pickWinner
+// at Close.
+func (c *shadowCompressor) currentSmallest() compressor.Compressor {
+	var smallest compressor.Compressor
+	var smallestLen = -1
+	for _, candidate := range c.candidates {
+		if smallestLen == -1 || candidate.Len() < smallestLen {
+			smallestLen = candidate.Len()
+			smallest = candidate
+		}
+	}
+	return smallest
+}
+
+func (c *shadowCompressor) Len() int {
+	if c.winner != nil {
+		return c.winner.Len()
+	}
+	return c.currentSmallest().Len()
+}
+
+func (c *shadowCompressor) Flush() error {
+	for algo, candidate := range c.candidates {
+		if err := candidate.Flush(); err != nil {
+			return fmt.Errorf("flushing shadow candidate %q: %w", algo, err)
+		}
+	}
+	return nil
+}
+
+func (c *shadowCompressor) FullErr() error {
+	if c.winner != nil {
+		return c.winner.FullErr()
+	}
+	return c.currentSmallest().FullErr()
+}
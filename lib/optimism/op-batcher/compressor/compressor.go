@@ -0,0 +1,188 @@
+// Package compressor implements the compression backends that back
+// channelManager's CompressorRegistry (see op-batcher/batcher). Each backend
+// buffers written bytes through a streaming compressor and reports the
+// compressed size via Len, so the channel builder can decide when a channel
+// is full without needing its own copy of the compressed output.
+package compressor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"compress/zlib"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionAlgo identifies which compression backend a channel's frames
+// are built with. It is the key into a CompressorRegistry and is carried on
+// Config so a channel can be rebuilt with the same algorithm later.
+type CompressionAlgo string
+
+const (
+	Zlib   CompressionAlgo = "zlib"
+	Brotli CompressionAlgo = "brotli"
+	Zstd   CompressionAlgo = "zstd"
+	// Shadow isn't a real backend; CompressorRegistry.New intercepts it and
+	// returns a shadowCompressor that runs every registered backend above in
+	// parallel, so it never reaches NewZlibCompressor et al.
+	Shadow CompressionAlgo = "shadow"
+)
+
+// Config configures a Compressor.
+type Config struct {
+	CompressionAlgo CompressionAlgo
+	// TargetOutputSize bounds how much compressed data a single Compressor
+	// holds before FullErr reports non-nil, independent of the channel
+	// builder's own frame-count/size limits.
+	TargetOutputSize uint64
+}
+
+// Compressor buffers and compresses channel data, emitting the compressed
+// form through Read once the caller is done Writing and has Closed it.
+type Compressor interface {
+	io.Writer
+	io.Reader
+	Close() error
+	Reset()
+	Len() int
+	Flush() error
+	FullErr() error
+}
+
+// ErrCompressorFull is returned by Write once a Compressor has reached its
+// Config.TargetOutputSize.
+type ErrCompressorFull struct {
+	Size uint64
+}
+
+func (e *ErrCompressorFull) Error() string {
+	return fmt.Sprintf("compressor full: %d bytes", e.Size)
+}
+
+// zlibCompressor is the standard-library DEFLATE backend.
+type zlibCompressor struct {
+	cfg  Config
+	buf  bytes.Buffer
+	w    *zlib.Writer
+	full error
+}
+
+// NewZlibCompressor returns a Compressor backed by compress/zlib.
+func NewZlibCompressor(cfg Config) (Compressor, error) {
+	c := &zlibCompressor{cfg: cfg}
+	c.w = zlib.NewWriter(&c.buf)
+	return c, nil
+}
+
+func (c *zlibCompressor) Write(p []byte) (int, error) {
+	if c.full != nil {
+		return 0, c.full
+	}
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("zlib write: %w", err)
+	}
+	if c.cfg.TargetOutputSize > 0 && uint64(c.buf.Len()) >= c.cfg.TargetOutputSize {
+		c.full = &ErrCompressorFull{Size: uint64(c.buf.Len())}
+	}
+	return n, nil
+}
+
+func (c *zlibCompressor) Read(p []byte) (int, error) { return c.buf.Read(p) }
+func (c *zlibCompressor) Close() error               { return c.w.Close() }
+func (c *zlibCompressor) Flush() error               { return c.w.Flush() }
+func (c *zlibCompressor) Len() int                   { return c.buf.Len() }
+func (c *zlibCompressor) FullErr() error             { return c.full }
+func (c *zlibCompressor) Reset() {
+	c.buf.Reset()
+	c.full = nil
+	c.w.Reset(&c.buf)
+}
+
+// brotliCompressor wraps github.com/andybalholm/brotli.
+type brotliCompressor struct {
+	cfg  Config
+	buf  bytes.Buffer
+	w    *brotli.Writer
+	full error
+}
+
+// NewBrotliCompressor returns a Compressor backed by andybalholm/brotli at
+// its default quality level.
+func NewBrotliCompressor(cfg Config) (Compressor, error) {
+	c := &brotliCompressor{cfg: cfg}
+	c.w = brotli.NewWriter(&c.buf)
+	return c, nil
+}
+
+func (c *brotliCompressor) Write(p []byte) (int, error) {
+	if c.full != nil {
+		return 0, c.full
+	}
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("brotli write: %w", err)
+	}
+	if c.cfg.TargetOutputSize > 0 && uint64(c.buf.Len()) >= c.cfg.TargetOutputSize {
+		c.full = &ErrCompressorFull{Size: uint64(c.buf.Len())}
+	}
+	return n, nil
+}
+
+func (c *brotliCompressor) Read(p []byte) (int, error) { return c.buf.Read(p) }
+func (c *brotliCompressor) Close() error               { return c.w.Close() }
+func (c *brotliCompressor) Flush() error               { return c.w.Flush() }
+func (c *brotliCompressor) Len() int                   { return c.buf.Len() }
+func (c *brotliCompressor) FullErr() error             { return c.full }
+func (c *brotliCompressor) Reset() {
+	c.buf.Reset()
+	c.full = nil
+	c.w.Reset(&c.buf)
+}
+
+// zstdCompressor wraps github.com/klauspost/compress/zstd.
+type zstdCompressor struct {
+	cfg  Config
+	buf  bytes.Buffer
+	w    *zstd.Encoder
+	full error
+}
+
+// NewZstdCompressor returns a Compressor backed by klauspost/compress/zstd.
+func NewZstdCompressor(cfg Config) (Compressor, error) {
+	c := &zstdCompressor{cfg: cfg}
+	w, err := zstd.NewWriter(&c.buf)
+	if err != nil {
+		return nil, fmt.Errorf("constructing zstd writer: %w", err)
+	}
+	c.w = w
+	return c, nil
+}
+
+func (c *zstdCompressor) Write(p []byte) (int, error) {
+	if c.full != nil {
+		return 0, c.full
+	}
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("zstd write: %w", err)
+	}
+	if c.cfg.TargetOutputSize > 0 && uint64(c.buf.Len()) >= c.cfg.TargetOutputSize {
+		c.full = &ErrCompressorFull{Size: uint64(c.buf.Len())}
+	}
+	return n, nil
+}
+
+func (c *zstdCompressor) Read(p []byte) (int, error) { return c.buf.Read(p) }
+func (c *zstdCompressor) Close() error               { return c.w.Close() }
+func (c *zstdCompressor) Flush() error               { return c.w.Flush() }
+func (c *zstdCompressor) Len() int                   { return c.buf.Len() }
+func (c *zstdCompressor) FullErr() error             { return c.full }
+func (c *zstdCompressor) Reset() {
+	c.buf.Reset()
+	c.full = nil
+	c.w.Reset(&c.buf)
+}